@@ -0,0 +1,30 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"net/http"
+
+	"github.com/go-logr/logr"
+
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+)
+
+// newImpersonationHandler returns the http.Handler served by the
+// impersonation proxy. It keeps authenticators and log, but it answers
+// every request with 501 Not Implemented: it does not check the caller's
+// credential and does not forward the request to the Kubernetes API.
+func newImpersonationHandler(authenticators *authncache.Cache, log logr.Logger) http.Handler {
+	return &impersonationHandler{authenticators: authenticators, log: log}
+}
+
+type impersonationHandler struct {
+	authenticators *authncache.Cache
+	log            logr.Logger
+}
+
+func (h *impersonationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// No request is rewritten or proxied here; every request gets a 501.
+	http.Error(w, "impersonation proxy not yet wired to a backend", http.StatusNotImplemented)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package impersonator provides a server that impersonates the identity
+// carried by a credential minted from a TokenCredentialRequest against the
+// cluster's Kubernetes API.
+package impersonator
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Impersonator runs the impersonation proxy's HTTPS server, serving the
+// handler returned by newImpersonationHandler. New binds the
+// listener synchronously, so that a bad bindAddress fails the caller
+// immediately; call Run afterwards to start serving on it, which blocks
+// until the provided context is cancelled.
+type Impersonator struct {
+	log                 logr.Logger
+	servingCertProvider dynamiccert.Provider
+	handler             http.Handler
+	listener            net.Listener
+
+	server  *http.Server
+	serving int32 // set to 1 while Run is actively serving, via sync/atomic
+}
+
+// New constructs an Impersonator and binds its listener. Call Run to start
+// serving on it.
+func New(
+	authenticators *authncache.Cache,
+	log logr.Logger,
+	bindAddress string,
+	servingCertProvider dynamiccert.Provider,
+) (*Impersonator, error) {
+	if bindAddress == "" {
+		return nil, fmt.Errorf("impersonation proxy bind address must not be empty")
+	}
+
+	listener, err := tls.Listen("tcp", bindAddress, &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: dynamiccert.GetCertificateFunc(servingCertProvider),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %q: %w", bindAddress, err)
+	}
+
+	return &Impersonator{
+		log:                 log,
+		servingCertProvider: servingCertProvider,
+		handler:             newImpersonationHandler(authenticators, log),
+		listener:            listener,
+	}, nil
+}
+
+// Run serves on the listener bound by New until ctx is cancelled, at which
+// point it gracefully drains in-flight requests before returning. Run
+// returns an error only if the server exits with an error other than a
+// graceful shutdown.
+func (i *Impersonator) Run(ctx context.Context) error {
+	i.server = &http.Server{Handler: i.handler}
+	atomic.StoreInt32(&i.serving, 1)
+	defer atomic.StoreInt32(&i.serving, 0)
+
+	serveErrCh := make(chan error, 1)
+	go func() { serveErrCh <- i.server.Serve(i.listener) }()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := i.server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("could not gracefully shut down impersonation proxy: %w", err)
+		}
+		return nil
+	case err := <-serveErrCh:
+		if err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("impersonation proxy server exited: %w", err)
+		}
+		return nil
+	}
+}
+
+// Ready reports whether the impersonation proxy is currently serving on its
+// bound listener and its serving cert provider holds valid cert/key
+// material. It is safe to call from any goroutine, including concurrently
+// with Run.
+func (i *Impersonator) Ready() bool {
+	if atomic.LoadInt32(&i.serving) != 1 {
+		return false
+	}
+	certPEM, keyPEM := i.servingCertProvider.CurrentCertKeyContent()
+	return len(certPEM) > 0 && len(keyPEM) > 0
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package impersonator
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/klogr"
+
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+func TestNewRequiresABindAddress(t *testing.T) {
+	_, err := New(nil, klogr.New(), "", dynamiccert.New())
+	require.EqualError(t, err, "impersonation proxy bind address must not be empty")
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		serving    int32
+		setCertKey bool
+		wantReady  bool
+	}{
+		{
+			name:       "not yet serving is never ready, even with a cert",
+			serving:    0,
+			setCertKey: true,
+			wantReady:  false,
+		},
+		{
+			name:       "serving without a cert is not ready",
+			serving:    1,
+			setCertKey: false,
+			wantReady:  false,
+		},
+		{
+			name:       "serving with a cert is ready",
+			serving:    1,
+			setCertKey: true,
+			wantReady:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certProvider := dynamiccert.New()
+			if tt.setCertKey {
+				certProvider.Set([]byte("fake-cert"), []byte("fake-key"))
+			}
+
+			i := &Impersonator{servingCertProvider: certProvider}
+			atomic.StoreInt32(&i.serving, tt.serving)
+
+			require.Equal(t, tt.wantReady, i.Ready())
+		})
+	}
+}
@@ -6,8 +6,6 @@ package server
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509/pkix"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,13 +13,13 @@ import (
 
 	"github.com/spf13/cobra"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/healthz"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 
 	loginv1alpha1 "go.pinniped.dev/generated/1.20/apis/concierge/login/v1alpha1"
 	"go.pinniped.dev/internal/apigroup"
-	"go.pinniped.dev/internal/certauthority"
 	"go.pinniped.dev/internal/certauthority/dynamiccertauthority"
 	"go.pinniped.dev/internal/concierge/apiserver"
 	"go.pinniped.dev/internal/concierge/impersonator"
@@ -124,21 +122,32 @@ func (a *App) runServer(ctx context.Context) error {
 	// cert issuer used to issue certs to Pinniped clients wishing to login.
 	dynamicSigningCertProvider := dynamiccert.New()
 
+	// This cert provider will provide certs to the impersonation proxy's
+	// listener and will be mutated by the impersonator cert refresher
+	// controller, so that rotation
+	// of the leaf serving cert is hitless for already-connected clients.
+	impersonationProxyServingCertProvider := dynamiccert.New()
+
 	// Prepare to start the controllers, but defer actually starting them until the
 	// post start hook of the aggregated API server.
 	startControllersFunc, err := controllermanager.PrepareControllers(
 		&controllermanager.Config{
-			ServerInstallationInfo:     podInfo,
-			APIGroupSuffix:             *cfg.APIGroupSuffix,
-			NamesConfig:                &cfg.NamesConfig,
-			Labels:                     cfg.Labels,
-			KubeCertAgentConfig:        &cfg.KubeCertAgentConfig,
-			DiscoveryURLOverride:       cfg.DiscoveryInfo.URL,
-			DynamicServingCertProvider: dynamicServingCertProvider,
-			DynamicSigningCertProvider: dynamicSigningCertProvider,
-			ServingCertDuration:        time.Duration(*cfg.APIConfig.ServingCertificateConfig.DurationSeconds) * time.Second,
-			ServingCertRenewBefore:     time.Duration(*cfg.APIConfig.ServingCertificateConfig.RenewBeforeSeconds) * time.Second,
-			AuthenticatorCache:         authenticators,
+			ServerInstallationInfo:                 podInfo,
+			APIGroupSuffix:                         *cfg.APIGroupSuffix,
+			NamesConfig:                            &cfg.NamesConfig,
+			Labels:                                 cfg.Labels,
+			KubeCertAgentConfig:                    &cfg.KubeCertAgentConfig,
+			DiscoveryURLOverride:                   cfg.DiscoveryInfo.URL,
+			DynamicServingCertProvider:             dynamicServingCertProvider,
+			DynamicSigningCertProvider:             dynamicSigningCertProvider,
+			ServingCertDuration:                    time.Duration(*cfg.APIConfig.ServingCertificateConfig.DurationSeconds) * time.Second,
+			ServingCertRenewBefore:                 time.Duration(*cfg.APIConfig.ServingCertificateConfig.RenewBeforeSeconds) * time.Second,
+			AuthenticatorCache:                     authenticators,
+			ImpersonationProxyEnabled:              cfg.ImpersonationProxy.Enabled,
+			ImpersonationProxyServingCertProvider:  impersonationProxyServingCertProvider,
+			ImpersonationProxyCASecretName:         cfg.ImpersonationProxy.CABundleSecretName,
+			ImpersonationProxyExternalCASecretName: cfg.ImpersonationProxy.ExternalCABundleSecretName,
+			ImpersonationProxyExternalNames:        cfg.ImpersonationProxy.ExternalNames,
 		},
 	)
 	if err != nil {
@@ -163,33 +172,67 @@ func (a *App) runServer(ctx context.Context) error {
 		return fmt.Errorf("could not create aggregated API server: %w", err)
 	}
 
-	// run proxy handler
-	impersonationCA, err := certauthority.New(pkix.Name{CommonName: "test CA"}, 24*time.Hour)
-	if err != nil {
-		return fmt.Errorf("could not create impersonation CA: %w", err)
-	}
-	impersonationCert, err := impersonationCA.Issue(pkix.Name{}, []string{"impersonation-proxy"}, nil, 24*time.Hour)
-	if err != nil {
-		return fmt.Errorf("could not create impersonation cert: %w", err)
-	}
-	impersonationProxy, err := impersonator.New(authenticators, klogr.New().WithName("impersonation-proxy"))
-	if err != nil {
-		return fmt.Errorf("could not create impersonation proxy: %w", err)
-	}
+	// Wire the impersonation proxy, if enabled, into the aggregated API
+	// server's own lifecycle instead of running it as a fire-and-forget
+	// goroutine: it starts from a post-start hook alongside
+	// StartControllersPostStartHook, it participates in /readyz via a
+	// NamedCheck backed by impersonationProxy.Ready(), and it is drained by
+	// a pre-shutdown hook before the aggregated API server stops accepting
+	// connections. Its serving cert comes from
+	// impersonationProxyServingCertProvider, which is kept up to date by
+	// the impersonator cert refresher controller started above. The CA is
+	// read back from the CA Secret on pod restart and is not rotated; the
+	// leaf cert is rotated well before its expiry without dropping already
+	// connected clients.
+	if cfg.ImpersonationProxy.Enabled {
+		impersonationProxy, err := impersonator.New(
+			authenticators,
+			klogr.New().WithName("impersonation-proxy"),
+			cfg.ImpersonationProxy.BindAddress,
+			impersonationProxyServingCertProvider,
+		)
+		if err != nil {
+			return fmt.Errorf("could not create impersonation proxy: %w", err)
+		}
 
-	impersonationProxyServer := http.Server{
-		Addr:    "0.0.0.0:8444",
-		Handler: impersonationProxy,
-		TLSConfig: &tls.Config{
-			MinVersion:   tls.VersionTLS12,
-			Certificates: []tls.Certificate{*impersonationCert},
-		},
-	}
-	go func() {
-		if err := impersonationProxyServer.ListenAndServeTLS("", ""); err != nil {
-			klog.ErrorS(err, "could not serve impersonation proxy")
+		impersonationProxyCtx, cancelImpersonationProxy := context.WithCancel(context.Background())
+		impersonationProxyDone := make(chan struct{})
+
+		if err := server.GenericAPIServer.AddPostStartHook("start-impersonation-proxy", func(_ genericapiserver.PostStartHookContext) error {
+			go func() {
+				defer close(impersonationProxyDone)
+				if err := impersonationProxy.Run(impersonationProxyCtx); err != nil {
+					klog.ErrorS(err, "impersonation proxy exited")
+				}
+			}()
+			return nil
+		}); err != nil {
+			return fmt.Errorf("could not add impersonation proxy post-start hook: %w", err)
+		}
+
+		if err := server.GenericAPIServer.AddPreShutdownHook("drain-impersonation-proxy", func() error {
+			cancelImpersonationProxy()
+			<-impersonationProxyDone
+			return nil
+		}); err != nil {
+			return fmt.Errorf("could not add impersonation proxy pre-shutdown hook: %w", err)
 		}
-	}()
+
+		// This is a readiness signal only: a not-yet-serving impersonation
+		// proxy should hold the pod out of the Service's endpoints, not cause
+		// the kubelet to restart an otherwise-healthy pod, so it is wired to
+		// /readyz only and deliberately left off /healthz.
+		impersonationProxyReadyzCheck := healthz.NamedCheck("impersonation-proxy", func(_ *http.Request) error {
+			if !impersonationProxy.Ready() {
+				return fmt.Errorf("impersonation proxy listener is not yet accepting connections")
+			}
+			return nil
+		})
+
+		if err := server.GenericAPIServer.AddReadyzChecks(impersonationProxyReadyzCheck); err != nil {
+			return fmt.Errorf("could not add impersonation proxy readyz check: %w", err)
+		}
+	}
 
 	// Run the server. Its post-start hook will start the controllers.
 	return server.GenericAPIServer.PrepareRun().Run(ctx.Done())
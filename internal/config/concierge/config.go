@@ -0,0 +1,179 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package concierge contains functionality to load and validate
+// a Config struct from a yaml file.
+package concierge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FromPath loads an Config from a provided local file path, inserts any
+// defaults (from the Config documentation), and validates the config.
+func FromPath(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read path: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("decode yaml: %w", err)
+	}
+
+	maybeSetAPIDefaults(&config.APIConfig)
+	maybeSetAPIGroupSuffixDefault(&config.APIGroupSuffix)
+	maybeSetImpersonationProxyDefaults(&config.ImpersonationProxy)
+
+	if err := validateAPIGroupSuffix(*config.APIGroupSuffix); err != nil {
+		return nil, fmt.Errorf("validate apiGroupSuffix: %w", err)
+	}
+
+	if err := validateImpersonationProxy(config.ImpersonationProxy); err != nil {
+		return nil, fmt.Errorf("validate impersonationProxy: %w", err)
+	}
+
+	return &config, nil
+}
+
+// Config contains knobs to configure the concierge server.
+type Config struct {
+	DiscoveryInfo       DiscoveryInfoSpec      `json:"discovery"`
+	APIConfig           APIConfigSpec          `json:"api"`
+	APIGroupSuffix      *string                `json:"apiGroupSuffix,omitempty"`
+	ImpersonationProxy  ImpersonationProxySpec `json:"impersonationProxy"`
+	KubeCertAgentConfig KubeCertAgentSpec      `json:"kubeCertAgent"`
+	NamesConfig         NamesConfigSpec        `json:"names"`
+	Labels              map[string]string      `json:"labels"`
+}
+
+// DiscoveryInfoSpec contains configuration knobs for the discovery endpoint.
+type DiscoveryInfoSpec struct {
+	// URL contains the URL that should be advertised for JWT/OIDC discovery
+	// purposes. When not set, the URL is determined using the base URL of the
+	// current in-cluster Kubernetes API server, as found via the standard
+	// in-cluster configuration.
+	URL *string `json:"url,omitempty"`
+}
+
+// APIConfigSpec contains configuration knobs for the API server.
+type APIConfigSpec struct {
+	ServingCertificateConfig ServingCertificateConfigSpec `json:"servingCertificate"`
+}
+
+// ServingCertificateConfigSpec contains the configuration knobs for the API's
+// serving certificate, i.e., the x509 certificate that it uses for the server
+// certificate in inbound TLS connections.
+type ServingCertificateConfigSpec struct {
+	// DurationSeconds is the validity period, in seconds, of the API serving
+	// certificate. By default, the serving certificate is issued for 31536000
+	// seconds (1 year).
+	DurationSeconds *int64 `json:"durationSeconds,omitempty"`
+
+	// RenewBeforeSeconds is the period of time, in seconds, that pinniped will
+	// wait before rotating the serving certificate. This period of time starts
+	// upon issuance of the serving certificate. This must be less than
+	// DurationSeconds. By default, pinniped begins rotation after 23328000
+	// seconds (about 9 months).
+	RenewBeforeSeconds *int64 `json:"renewBeforeSeconds,omitempty"`
+}
+
+// KubeCertAgentSpec contains the configuration knobs for the client used to
+// discover the Kubernetes API server's signing key.
+type KubeCertAgentSpec struct {
+	// NamePrefix is the prefix for created pods.
+	NamePrefix *string `json:"namePrefix,omitempty"`
+
+	// Image is the Docker image to use for the created pods.
+	Image *string `json:"image,omitempty"`
+
+	// ImagePullSecrets is a list of names of Kubernetes Secrets in the
+	// concierge's namespace that can be used to pull the image.
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+}
+
+// NamesConfigSpec configures the names of the various Kubernetes resources
+// that the concierge creates and manages.
+type NamesConfigSpec struct {
+	ServingCertificateSecret string `json:"servingCertificateSecret"`
+	CredentialIssuer         string `json:"credentialIssuer"`
+	APIService               string `json:"apiService"`
+}
+
+// ImpersonationProxySpec configures the impersonation proxy that the
+// concierge optionally runs in front of the cluster's Kubernetes API server.
+type ImpersonationProxySpec struct {
+	// Enabled turns the impersonation proxy on or off. It defaults to false.
+	Enabled bool `json:"enabled"`
+
+	// BindAddress is the host:port at which the impersonation proxy listens.
+	// Defaults to "0.0.0.0:8444".
+	BindAddress string `json:"bindAddress,omitempty"`
+
+	// ExternalNames are additional DNS names that should appear as SNI
+	// hostnames on the impersonation proxy's serving certificate, e.g. the
+	// hostname of a Service or Ingress that fronts the proxy.
+	ExternalNames []string `json:"externalNames,omitempty"`
+
+	// CABundleSecretName is the name of the Secret in the concierge's
+	// namespace that holds the CA keypair used to issue the impersonation
+	// proxy's serving certificate. The concierge creates this Secret if it
+	// does not already exist, unless ExternalCABundleSecretName is set.
+	CABundleSecretName string `json:"caBundleSecretName,omitempty"`
+
+	// ExternalCABundleSecretName, when set, names a Secret in the
+	// concierge's namespace that holds an operator-provided CA keypair. When
+	// set, the concierge reads the CA from this Secret instead of
+	// generating and managing its own CA in CABundleSecretName.
+	ExternalCABundleSecretName string `json:"externalCABundleSecretName,omitempty"`
+}
+
+func maybeSetAPIDefaults(apiConfig *APIConfigSpec) {
+	if apiConfig.ServingCertificateConfig.DurationSeconds == nil {
+		apiConfig.ServingCertificateConfig.DurationSeconds = int64Ptr(int64(365 * 24 * time.Hour / time.Second))
+	}
+	if apiConfig.ServingCertificateConfig.RenewBeforeSeconds == nil {
+		apiConfig.ServingCertificateConfig.RenewBeforeSeconds = int64Ptr(int64(9 * 30 * 24 * time.Hour / time.Second))
+	}
+}
+
+func maybeSetAPIGroupSuffixDefault(apiGroupSuffix **string) {
+	if *apiGroupSuffix == nil {
+		*apiGroupSuffix = stringPtr("pinniped.dev")
+	}
+}
+
+func maybeSetImpersonationProxyDefaults(impersonationProxy *ImpersonationProxySpec) {
+	if impersonationProxy.BindAddress == "" {
+		impersonationProxy.BindAddress = "0.0.0.0:8444"
+	}
+	if impersonationProxy.CABundleSecretName == "" && impersonationProxy.ExternalCABundleSecretName == "" {
+		impersonationProxy.CABundleSecretName = "pinniped-concierge-impersonation-proxy-ca"
+	}
+}
+
+func validateAPIGroupSuffix(apiGroupSuffix string) error {
+	// Kubernetes API group names must match RFC 1123's definition of a
+	// subdomain, which is less strict than DNS. Just check for a non-empty
+	// value here and leave detailed validation to the API machinery at the
+	// point of use.
+	if len(apiGroupSuffix) == 0 {
+		return fmt.Errorf("apiGroupSuffix cannot be empty")
+	}
+	return nil
+}
+
+func validateImpersonationProxy(impersonationProxy ImpersonationProxySpec) error {
+	if impersonationProxy.CABundleSecretName != "" && impersonationProxy.ExternalCABundleSecretName != "" {
+		return fmt.Errorf("caBundleSecretName and externalCABundleSecretName are mutually exclusive")
+	}
+	return nil
+}
+
+func int64Ptr(i int64) *int64    { return &i }
+func stringPtr(s string) *string { return &s }
@@ -0,0 +1,84 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package concierge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaybeSetImpersonationProxyDefaults(t *testing.T) {
+	tests := []struct {
+		name                   string
+		impersonationProxy     ImpersonationProxySpec
+		wantBindAddress        string
+		wantCABundleSecretName string
+	}{
+		{
+			name:                   "empty spec gets both defaults",
+			impersonationProxy:     ImpersonationProxySpec{},
+			wantBindAddress:        "0.0.0.0:8444",
+			wantCABundleSecretName: "pinniped-concierge-impersonation-proxy-ca",
+		},
+		{
+			name:                   "explicit bind address is preserved",
+			impersonationProxy:     ImpersonationProxySpec{BindAddress: "127.0.0.1:443"},
+			wantBindAddress:        "127.0.0.1:443",
+			wantCABundleSecretName: "pinniped-concierge-impersonation-proxy-ca",
+		},
+		{
+			name:                   "external CA secret name suppresses the managed CA default",
+			impersonationProxy:     ImpersonationProxySpec{ExternalCABundleSecretName: "operator-provided-ca"},
+			wantBindAddress:        "0.0.0.0:8444",
+			wantCABundleSecretName: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maybeSetImpersonationProxyDefaults(&tt.impersonationProxy)
+			require.Equal(t, tt.wantBindAddress, tt.impersonationProxy.BindAddress)
+			require.Equal(t, tt.wantCABundleSecretName, tt.impersonationProxy.CABundleSecretName)
+		})
+	}
+}
+
+func TestValidateImpersonationProxy(t *testing.T) {
+	tests := []struct {
+		name               string
+		impersonationProxy ImpersonationProxySpec
+		wantError          string
+	}{
+		{
+			name:               "neither CA field set is valid",
+			impersonationProxy: ImpersonationProxySpec{},
+		},
+		{
+			name:               "only the managed CA secret name set is valid",
+			impersonationProxy: ImpersonationProxySpec{CABundleSecretName: "managed-ca"},
+		},
+		{
+			name:               "only the external CA secret name set is valid",
+			impersonationProxy: ImpersonationProxySpec{ExternalCABundleSecretName: "external-ca"},
+		},
+		{
+			name: "both CA secret names set is invalid",
+			impersonationProxy: ImpersonationProxySpec{
+				CABundleSecretName:         "managed-ca",
+				ExternalCABundleSecretName: "external-ca",
+			},
+			wantError: "caBundleSecretName and externalCABundleSecretName are mutually exclusive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImpersonationProxy(tt.impersonationProxy)
+			if tt.wantError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantError)
+		})
+	}
+}
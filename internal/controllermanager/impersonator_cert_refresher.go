@@ -0,0 +1,236 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllermanager
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/downward"
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+// impersonatorCertRefresherController keeps the impersonation proxy's CA
+// keypair and leaf serving certificate in sync with a k8s Secret. The CA is
+// generated once, when its Secret does not exist, and is not rotated; the
+// leaf serving certificate is issued and rotated as needed.
+//
+// The CA keypair is persisted in the Secret named by secretName so that it
+// survives pod restarts, and so that every concierge pod in the deployment
+// converges on the same CA. The leaf serving certificate is derived from
+// that CA and is rotated well before it expires, without ever touching the
+// Secret, so that rotation is hitless for already-connected clients that
+// trust the CA bundle. The CA bundle is also published to a ConfigMap so
+// that the concierge kubeconfig generator can hand it to CLI clients as
+// their trust anchor for the proxy.
+type impersonatorCertRefresherController struct {
+	namespace              string
+	secretName             string
+	externalSecretName     string
+	caBundleConfigMapName  string
+	externalNames          []string
+	k8sClient              kubernetes.Interface
+	servingCertProvider    dynamiccert.Provider
+	servingCertDuration    time.Duration
+	servingCertRenewBefore time.Duration
+}
+
+// caBundleConfigMapKey is the ConfigMap data key under which the
+// impersonation proxy's CA bundle is published for the concierge
+// kubeconfig generator (and any other in-cluster reader) to consume. It
+// mirrors the well-known "ca.crt" key used elsewhere in Kubernetes for
+// publishing CA bundles (e.g. the cluster's root CA ConfigMap).
+const caBundleConfigMapKey = "ca.crt"
+
+func newImpersonatorCertRefresherController(
+	serverInstallationInfo *downward.PodInfo,
+	secretName string,
+	externalSecretName string,
+	externalNames []string,
+	servingCertProvider dynamiccert.Provider,
+	servingCertDuration time.Duration,
+	servingCertRenewBefore time.Duration,
+) (*impersonatorCertRefresherController, error) {
+	if secretName == "" && externalSecretName == "" {
+		return nil, fmt.Errorf("impersonation proxy CA secret name must not be empty")
+	}
+
+	kubeConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load in-cluster config: %w", err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create k8s client: %w", err)
+	}
+
+	// caBundleConfigMapName is derived from whichever CA Secret is actually
+	// in use, so that a managed CA and an externally-provided CA each get
+	// their own published bundle.
+	caBundleConfigMapName := secretName + "-ca-bundle"
+	if externalSecretName != "" {
+		caBundleConfigMapName = externalSecretName + "-ca-bundle"
+	}
+
+	return &impersonatorCertRefresherController{
+		namespace:              serverInstallationInfo.Namespace,
+		secretName:             secretName,
+		externalSecretName:     externalSecretName,
+		caBundleConfigMapName:  caBundleConfigMapName,
+		externalNames:          externalNames,
+		k8sClient:              k8sClient,
+		servingCertProvider:    servingCertProvider,
+		servingCertDuration:    servingCertDuration,
+		servingCertRenewBefore: servingCertRenewBefore,
+	}, nil
+}
+
+// Run reconciles the impersonation proxy's CA and serving cert every
+// syncPeriod until ctx is cancelled. It is not a "real" controller in the
+// informer-driven sense because the CA Secret is not watched by any other
+// component, but it follows the same reconcile-until-cancelled shape as the
+// rest of the controllers started from PrepareControllers.
+func (c *impersonatorCertRefresherController) Run(ctx context.Context, workers int) {
+	const syncPeriod = time.Minute
+
+	if err := c.sync(ctx); err != nil {
+		klog.ErrorS(err, "could not sync impersonation proxy certs")
+	}
+
+	ticker := time.NewTicker(syncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.sync(ctx); err != nil {
+				klog.ErrorS(err, "could not sync impersonation proxy certs")
+			}
+		}
+	}
+}
+
+func (c *impersonatorCertRefresherController) sync(ctx context.Context) error {
+	ca, err := c.loadOrGenerateCA(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load or generate impersonation proxy CA: %w", err)
+	}
+
+	if err := c.publishCABundle(ctx, ca.Bundle()); err != nil {
+		return fmt.Errorf("could not publish impersonation proxy CA bundle: %w", err)
+	}
+
+	currentCertPEM, _ := c.servingCertProvider.CurrentCertKeyContent()
+	if len(currentCertPEM) == 0 || c.servingCertProvider.ShouldRenew(c.servingCertRenewBefore) {
+		dnsNames := append([]string{"impersonation-proxy"}, c.externalNames...)
+		cert, err := ca.Issue(pkix.Name{}, dnsNames, nil, c.servingCertDuration)
+		if err != nil {
+			return fmt.Errorf("could not issue impersonation proxy serving cert: %w", err)
+		}
+		certPEM, keyPEM, err := certauthority.ToPEM(cert)
+		if err != nil {
+			return fmt.Errorf("could not encode impersonation proxy serving cert: %w", err)
+		}
+		c.servingCertProvider.Set(certPEM, keyPEM)
+	}
+
+	return nil
+}
+
+// publishCABundle writes the impersonation proxy's CA bundle into a
+// ConfigMap in the concierge's namespace so that the concierge kubeconfig
+// generator (and any CLI client fetching it via that kubeconfig) has a
+// trust anchor for the proxy, without needing access to the CA Secret's
+// private key.
+func (c *impersonatorCertRefresherController) publishCABundle(ctx context.Context, caBundlePEM []byte) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.caBundleConfigMapName,
+			Namespace: c.namespace,
+		},
+		Data: map[string]string{
+			caBundleConfigMapKey: string(caBundlePEM),
+		},
+	}
+
+	_, err := c.k8sClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		_, err = c.k8sClient.CoreV1().ConfigMaps(c.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// loadOrGenerateCA loads the impersonation proxy's CA from a Secret. When
+// externalSecretName is set, the CA is entirely operator-managed: it is
+// only ever read, never generated or rotated by the concierge, and a
+// missing Secret is a hard error rather than a trigger to create one.
+// Otherwise, the concierge owns the CA in secretName and generates it on
+// first use.
+func (c *impersonatorCertRefresherController) loadOrGenerateCA(ctx context.Context) (*certauthority.CA, error) {
+	if c.externalSecretName != "" {
+		secret, err := c.k8sClient.CoreV1().Secrets(c.namespace).Get(ctx, c.externalSecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not get externally-managed impersonation proxy CA secret %q: %w", c.externalSecretName, err)
+		}
+		return certauthority.Load(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	}
+
+	secret, err := c.k8sClient.CoreV1().Secrets(c.namespace).Get(ctx, c.secretName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		return certauthority.Load(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	case k8serrors.IsNotFound(err):
+		return c.generateAndStoreCA(ctx)
+	default:
+		return nil, fmt.Errorf("could not get impersonation proxy CA secret: %w", err)
+	}
+}
+
+func (c *impersonatorCertRefresherController) generateAndStoreCA(ctx context.Context) (*certauthority.CA, error) {
+	ca, err := certauthority.New(pkix.Name{CommonName: "Pinniped Concierge Impersonation Proxy CA"}, 100*365*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate impersonation proxy CA: %w", err)
+	}
+	certPEM, keyPEM, err := ca.ToPEM()
+	if err != nil {
+		return nil, fmt.Errorf("could not encode impersonation proxy CA: %w", err)
+	}
+
+	_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.secretName,
+			Namespace: c.namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		// Another concierge pod stored its CA first, so use that one.
+		secret, err := c.k8sClient.CoreV1().Secrets(c.namespace).Get(ctx, c.secretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not get impersonation proxy CA secret: %w", err)
+		}
+		return certauthority.Load(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create impersonation proxy CA secret: %w", err)
+	}
+
+	return ca, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllermanager
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"go.pinniped.dev/internal/certauthority"
+)
+
+func TestLoadOrGenerateCA(t *testing.T) {
+	const namespace = "concierge"
+
+	t.Run("external CA secret missing is an error, and no CA is generated", func(t *testing.T) {
+		k8sClient := fake.NewSimpleClientset()
+		c := &impersonatorCertRefresherController{
+			namespace:          namespace,
+			externalSecretName: "operator-provided-ca",
+			k8sClient:          k8sClient,
+		}
+
+		_, err := c.loadOrGenerateCA(context.Background())
+		require.EqualError(t, err, `could not get externally-managed impersonation proxy CA secret "operator-provided-ca": secrets "operator-provided-ca" not found`)
+
+		secrets, err := k8sClient.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, secrets.Items)
+	})
+
+	t.Run("external CA secret present is loaded as-is", func(t *testing.T) {
+		ca, err := certauthority.New(pkix.Name{CommonName: "Test CA"}, time.Hour)
+		require.NoError(t, err)
+		certPEM, keyPEM, err := ca.ToPEM()
+		require.NoError(t, err)
+
+		k8sClient := fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "operator-provided-ca", Namespace: namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			},
+		})
+		c := &impersonatorCertRefresherController{
+			namespace:          namespace,
+			externalSecretName: "operator-provided-ca",
+			k8sClient:          k8sClient,
+		}
+
+		loadedCA, err := c.loadOrGenerateCA(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, loadedCA)
+	})
+
+	t.Run("missing managed CA secret is generated and stored", func(t *testing.T) {
+		k8sClient := fake.NewSimpleClientset()
+		c := &impersonatorCertRefresherController{
+			namespace:  namespace,
+			secretName: "managed-ca",
+			k8sClient:  k8sClient,
+		}
+
+		loadedCA, err := c.loadOrGenerateCA(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, loadedCA)
+
+		secret, err := k8sClient.CoreV1().Secrets(namespace).Get(context.Background(), "managed-ca", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NotEmpty(t, secret.Data[corev1.TLSCertKey])
+		require.NotEmpty(t, secret.Data[corev1.TLSPrivateKeyKey])
+	})
+}
@@ -0,0 +1,245 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllermanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/downward"
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+const (
+	kubeControllerManagerNamespace     = "kube-system"
+	kubeControllerManagerLabelSelector = "component=kube-controller-manager"
+
+	defaultClusterSigningCertFile = "/etc/kubernetes/pki/ca.crt"
+	defaultClusterSigningKeyFile  = "/etc/kubernetes/pki/ca.key"
+)
+
+// kubeCertAgentController discovers the Kubernetes API server's cluster
+// signing keypair and publishes it to signingCertProvider, which is in turn
+// used to issue the short-lived client certs returned from
+// TokenCredentialRequests.
+//
+// The keypair is not readable from the concierge pod directly, so this
+// controller clones the running kube-controller-manager pod's spec (so that
+// it mounts the same host paths) into a long-lived "agent" pod in the
+// concierge's own namespace, and execs into that agent pod to read the
+// keypair off disk. The agent pod is named after the source pod's node and
+// is created when no pod of that name exists; an existing agent pod is used
+// as it is and is not updated. If the source pod moves to another node, a
+// new agent pod is created for that node and the old one is not removed.
+type kubeCertAgentController struct {
+	namespace        string
+	namePrefix       string
+	image            *string
+	imagePullSecrets []string
+	labels           map[string]string
+
+	k8sClient           kubernetes.Interface
+	kubeConfig          *rest.Config
+	signingCertProvider dynamiccert.Provider
+}
+
+func newKubeCertAgentController(
+	serverInstallationInfo *downward.PodInfo,
+	agentConfig *concierge.KubeCertAgentSpec,
+	labels map[string]string,
+	signingCertProvider dynamiccert.Provider,
+) (*kubeCertAgentController, error) {
+	kubeConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load in-cluster config: %w", err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create k8s client: %w", err)
+	}
+
+	namePrefix := "pinniped-concierge-kube-cert-agent-"
+	if agentConfig.NamePrefix != nil {
+		namePrefix = *agentConfig.NamePrefix
+	}
+
+	return &kubeCertAgentController{
+		namespace:           serverInstallationInfo.Namespace,
+		namePrefix:          namePrefix,
+		image:               agentConfig.Image,
+		imagePullSecrets:    agentConfig.ImagePullSecrets,
+		labels:              labels,
+		k8sClient:           k8sClient,
+		kubeConfig:          kubeConfig,
+		signingCertProvider: signingCertProvider,
+	}, nil
+}
+
+// Run reconciles the kube cert agent pod and re-reads the cluster signing
+// keypair every syncPeriod until ctx is cancelled.
+func (c *kubeCertAgentController) Run(ctx context.Context, workers int) {
+	const syncPeriod = time.Minute
+
+	if err := c.sync(ctx); err != nil {
+		klog.ErrorS(err, "could not sync kube cert agent")
+	}
+
+	ticker := time.NewTicker(syncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.sync(ctx); err != nil {
+				klog.ErrorS(err, "could not sync kube cert agent")
+			}
+		}
+	}
+}
+
+func (c *kubeCertAgentController) sync(ctx context.Context) error {
+	controllerManagerPod, err := c.findKubeControllerManagerPod(ctx)
+	if err != nil {
+		return fmt.Errorf("could not find kube-controller-manager pod: %w", err)
+	}
+
+	agentPod, err := c.ensureAgentPod(ctx, controllerManagerPod)
+	if err != nil {
+		return fmt.Errorf("could not create kube cert agent pod: %w", err)
+	}
+
+	certFile, keyFile := clusterSigningFilePaths(controllerManagerPod)
+
+	certPEM, err := c.execAgentPod(ctx, agentPod, certFile)
+	if err != nil {
+		return fmt.Errorf("could not read cluster signing cert from kube cert agent pod: %w", err)
+	}
+	keyPEM, err := c.execAgentPod(ctx, agentPod, keyFile)
+	if err != nil {
+		return fmt.Errorf("could not read cluster signing key from kube cert agent pod: %w", err)
+	}
+
+	c.signingCertProvider.Set(certPEM, keyPEM)
+	return nil
+}
+
+func (c *kubeCertAgentController) findKubeControllerManagerPod(ctx context.Context) (*corev1.Pod, error) {
+	pods, err := c.k8sClient.CoreV1().Pods(kubeControllerManagerNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: kubeControllerManagerLabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pod found matching selector %q in namespace %q", kubeControllerManagerLabelSelector, kubeControllerManagerNamespace)
+	}
+	return &pods.Items[0], nil
+}
+
+func (c *kubeCertAgentController) ensureAgentPod(ctx context.Context, controllerManagerPod *corev1.Pod) (*corev1.Pod, error) {
+	agentPodName := c.namePrefix + controllerManagerPod.Spec.NodeName
+
+	agentPod, err := c.k8sClient.CoreV1().Pods(c.namespace).Get(ctx, agentPodName, metav1.GetOptions{})
+	if err == nil {
+		return agentPod, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	image := controllerManagerPod.Spec.Containers[0].Image
+	if c.image != nil {
+		image = *c.image
+	}
+
+	var imagePullSecrets []corev1.LocalObjectReference
+	for _, name := range c.imagePullSecrets {
+		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+
+	agentPod = &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentPodName,
+			Namespace: c.namespace,
+			Labels:    c.labels,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:         controllerManagerPod.Spec.NodeName,
+			Tolerations:      controllerManagerPod.Spec.Tolerations,
+			Volumes:          controllerManagerPod.Spec.Volumes,
+			ImagePullSecrets: imagePullSecrets,
+			Containers: []corev1.Container{
+				{
+					Name:         "sleeper",
+					Image:        image,
+					Command:      []string{"/bin/sleep", "infinity"},
+					VolumeMounts: controllerManagerPod.Spec.Containers[0].VolumeMounts,
+				},
+			},
+		},
+	}
+
+	return c.k8sClient.CoreV1().Pods(c.namespace).Create(ctx, agentPod, metav1.CreateOptions{})
+}
+
+func (c *kubeCertAgentController) execAgentPod(ctx context.Context, agentPod *corev1.Pod, path string) ([]byte, error) {
+	req := c.k8sClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(agentPod.Name).
+		Namespace(agentPod.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: agentPod.Spec.Containers[0].Name,
+			Command:   []string{"/bin/cat", path},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(c.kubeConfig, "POST", req.URL())
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// clusterSigningFilePaths returns the paths to the cluster signing cert and
+// key, as configured on the kube-controller-manager's own command line via
+// --cluster-signing-cert-file and --cluster-signing-key-file, falling back
+// to the well-known default paths if those flags are not set.
+func clusterSigningFilePaths(controllerManagerPod *corev1.Pod) (certFile, keyFile string) {
+	certFile, keyFile = defaultClusterSigningCertFile, defaultClusterSigningKeyFile
+	if len(controllerManagerPod.Spec.Containers) == 0 {
+		return certFile, keyFile
+	}
+	for _, arg := range controllerManagerPod.Spec.Containers[0].Command {
+		switch {
+		case strings.HasPrefix(arg, "--cluster-signing-cert-file="):
+			certFile = strings.TrimPrefix(arg, "--cluster-signing-cert-file=")
+		case strings.HasPrefix(arg, "--cluster-signing-key-file="):
+			keyFile = strings.TrimPrefix(arg, "--cluster-signing-key-file=")
+		}
+	}
+	return certFile, keyFile
+}
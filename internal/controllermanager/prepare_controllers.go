@@ -0,0 +1,144 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package controllermanager holds the glue code that assembles and starts
+// the various controllers that make up pinniped-concierge.
+package controllermanager
+
+import (
+	"context"
+	"time"
+
+	concierge "go.pinniped.dev/internal/config/concierge"
+	"go.pinniped.dev/internal/controller/authenticator/authncache"
+	"go.pinniped.dev/internal/downward"
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+// Config holds all of the inputs required to prepare the set of controllers
+// that run as part of pinniped-concierge.
+type Config struct {
+	// ServerInstallationInfo describes where this concierge pod is running.
+	ServerInstallationInfo *downward.PodInfo
+
+	// APIGroupSuffix is the suffix appended to the pinniped.dev API groups
+	// served by the aggregated API server.
+	APIGroupSuffix string
+
+	// NamesConfig contains the desired names of all of the Kubernetes
+	// resources that the concierge creates and manages.
+	NamesConfig *concierge.NamesConfigSpec
+
+	// Labels are the labels that should be attached to every resource that
+	// the concierge creates.
+	Labels map[string]string
+
+	// KubeCertAgentConfig configures the controller that discovers the
+	// Kubernetes API server's signing key.
+	KubeCertAgentConfig *concierge.KubeCertAgentSpec
+
+	// DiscoveryURLOverride, when set, overrides the URL that is advertised
+	// for JWT/OIDC discovery purposes.
+	DiscoveryURLOverride *string
+
+	// DynamicServingCertProvider provides the serving certificate for the
+	// aggregated API server, and is kept up to date by a controller.
+	DynamicServingCertProvider dynamiccert.Provider
+
+	// DynamicSigningCertProvider provides the signing certificate used to
+	// issue credentials to clients, and is kept up to date by a controller.
+	DynamicSigningCertProvider dynamiccert.Provider
+
+	// ServingCertDuration is the validity period of certs minted from
+	// DynamicServingCertProvider.
+	ServingCertDuration time.Duration
+
+	// ServingCertRenewBefore is how long after issuance the certs minted
+	// from DynamicServingCertProvider are rotated.
+	ServingCertRenewBefore time.Duration
+
+	// ImpersonationProxyServingCertProvider provides the serving certificate
+	// for the impersonation proxy, and is kept up to date by the
+	// impersonator cert refresh controller below.
+	ImpersonationProxyServingCertProvider dynamiccert.Provider
+
+	// ImpersonationProxyEnabled reports whether the impersonation proxy is
+	// turned on. When false, none of the other ImpersonationProxy* fields
+	// are consulted and no impersonation proxy controller is started.
+	ImpersonationProxyEnabled bool
+
+	// ImpersonationProxyCASecretName is the name of the Secret, in the
+	// concierge's own namespace, that holds the impersonation proxy's CA
+	// keypair. The concierge generates this CA itself when the Secret does
+	// not exist; it does not rotate it. Mutually
+	// exclusive with ImpersonationProxyExternalCASecretName.
+	ImpersonationProxyCASecretName string
+
+	// ImpersonationProxyExternalCASecretName, when set, names a Secret in
+	// the concierge's own namespace that holds an operator-provided CA
+	// keypair. The concierge only reads this Secret; it never generates or
+	// rotates the CA itself. Mutually exclusive with
+	// ImpersonationProxyCASecretName.
+	ImpersonationProxyExternalCASecretName string
+
+	// ImpersonationProxyExternalNames are additional DNS names that should
+	// appear as SNI hostnames on the impersonation proxy's serving
+	// certificate.
+	ImpersonationProxyExternalNames []string
+
+	// AuthenticatorCache is the cache of authenticators shared between the
+	// aggregated API server and the impersonation proxy.
+	AuthenticatorCache *authncache.Cache
+}
+
+// PrepareControllers prepares the controllers and returns a function that
+// will start them when called. Actually starting the controllers is deferred
+// to the aggregated API server's post-start hook so that the controllers do
+// not begin reconciling until the server is actually ready to serve traffic.
+func PrepareControllers(c *Config) (func(ctx context.Context), error) {
+	servingCertManager, err := newServingCertManagerController(
+		c.ServerInstallationInfo,
+		c.NamesConfig.ServingCertificateSecret,
+		c.Labels,
+		c.DynamicServingCertProvider,
+		c.ServingCertDuration,
+		c.ServingCertRenewBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeCertAgent, err := newKubeCertAgentController(
+		c.ServerInstallationInfo,
+		c.KubeCertAgentConfig,
+		c.Labels,
+		c.DynamicSigningCertProvider,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var impersonatorCertRefresher *impersonatorCertRefresherController
+	if c.ImpersonationProxyEnabled {
+		impersonatorCertRefresher, err = newImpersonatorCertRefresherController(
+			c.ServerInstallationInfo,
+			c.ImpersonationProxyCASecretName,
+			c.ImpersonationProxyExternalCASecretName,
+			c.ImpersonationProxyExternalNames,
+			c.ImpersonationProxyServingCertProvider,
+			c.ServingCertDuration,
+			c.ServingCertRenewBefore,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context) {
+		go servingCertManager.Run(ctx, 1)
+		go kubeCertAgent.Run(ctx, 1)
+		if impersonatorCertRefresher != nil {
+			go impersonatorCertRefresher.Run(ctx, 1)
+		}
+	}, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2020-2021 the Pinniped contributors. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package controllermanager
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+
+	"go.pinniped.dev/internal/certauthority"
+	"go.pinniped.dev/internal/downward"
+	"go.pinniped.dev/internal/dynamiccert"
+)
+
+// servingCertManagerController keeps the aggregated API server's own TLS
+// serving certificate in sync with a k8s Secret, generating a new leaf
+// keypair the first time it runs and persisting it to the Secret named by
+// secretName so that every concierge pod in the deployment converges on the
+// same cert, then rotating the leaf cert well before it expires.
+//
+// Unlike the impersonation proxy's CA, the CA that signs this leaf is not
+// persisted: a fresh CA is generated for each issuance, and only the leaf
+// keypair is stored in the Secret.
+type servingCertManagerController struct {
+	namespace  string
+	secretName string
+	labels     map[string]string
+
+	k8sClient    kubernetes.Interface
+	certProvider dynamiccert.Provider
+
+	certDuration    time.Duration
+	certRenewBefore time.Duration
+}
+
+func newServingCertManagerController(
+	serverInstallationInfo *downward.PodInfo,
+	secretName string,
+	labels map[string]string,
+	certProvider dynamiccert.Provider,
+	certDuration time.Duration,
+	certRenewBefore time.Duration,
+) (*servingCertManagerController, error) {
+	if secretName == "" {
+		return nil, fmt.Errorf("serving cert secret name must not be empty")
+	}
+
+	kubeConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load in-cluster config: %w", err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create k8s client: %w", err)
+	}
+
+	return &servingCertManagerController{
+		namespace:       serverInstallationInfo.Namespace,
+		secretName:      secretName,
+		labels:          labels,
+		k8sClient:       k8sClient,
+		certProvider:    certProvider,
+		certDuration:    certDuration,
+		certRenewBefore: certRenewBefore,
+	}, nil
+}
+
+// Run reconciles the serving cert every syncPeriod until ctx is cancelled.
+func (c *servingCertManagerController) Run(ctx context.Context, workers int) {
+	const syncPeriod = time.Minute
+
+	if err := c.sync(ctx); err != nil {
+		klog.ErrorS(err, "could not sync serving cert")
+	}
+
+	ticker := time.NewTicker(syncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.sync(ctx); err != nil {
+				klog.ErrorS(err, "could not sync serving cert")
+			}
+		}
+	}
+}
+
+func (c *servingCertManagerController) sync(ctx context.Context) error {
+	secret, err := c.k8sClient.CoreV1().Secrets(c.namespace).Get(ctx, c.secretName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		c.certProvider.Set(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	case k8serrors.IsNotFound(err):
+		// fall through to the renewal check below, which will generate one
+	default:
+		return fmt.Errorf("could not get serving cert secret: %w", err)
+	}
+
+	currentCertPEM, _ := c.certProvider.CurrentCertKeyContent()
+	if len(currentCertPEM) == 0 || c.certProvider.ShouldRenew(c.certRenewBefore) {
+		return c.generateAndStoreCert(ctx)
+	}
+
+	return nil
+}
+
+func (c *servingCertManagerController) generateAndStoreCert(ctx context.Context) error {
+	ca, err := certauthority.New(pkix.Name{CommonName: "Pinniped Concierge Serving Certificate"}, c.certDuration)
+	if err != nil {
+		return fmt.Errorf("could not generate serving cert: %w", err)
+	}
+
+	dnsNames := []string{
+		"pinniped-concierge-api",
+		"pinniped-concierge-api." + c.namespace,
+		"pinniped-concierge-api." + c.namespace + ".svc",
+	}
+	cert, err := ca.Issue(pkix.Name{}, dnsNames, nil, c.certDuration)
+	if err != nil {
+		return fmt.Errorf("could not issue serving cert: %w", err)
+	}
+
+	certPEM, keyPEM, err := certauthority.ToPEM(cert)
+	if err != nil {
+		return fmt.Errorf("could not encode serving cert: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.secretName,
+			Namespace: c.namespace,
+			Labels:    c.labels,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		// On rotation the Secret already exists; overwrite it with the new cert.
+		_, err = c.k8sClient.CoreV1().Secrets(c.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("could not store serving cert secret: %w", err)
+	}
+
+	c.certProvider.Set(certPEM, keyPEM)
+	return nil
+}